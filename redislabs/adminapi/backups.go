@@ -0,0 +1,48 @@
+// Package adminapi exposes broker-internal HTTP endpoints that are not part
+// of the Open Service Broker API, for operators to inspect and manage
+// instance state directly.
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/instancemanagers"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// BackupsHandler lists and restores an instance's snapshot history.
+type BackupsHandler struct {
+	Manager   instancemanagers.BackupManager
+	Persister persisters.StatePersister
+}
+
+// ListSnapshots handles GET /admin/instances/{instance_id}/backups.
+func (h *BackupsHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+
+	snapshots, err := h.Manager.ListSnapshots(instanceID, h.Persister)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// Restore handles POST /admin/instances/{instance_id}/backups/{snapshot_id}/restore.
+func (h *BackupsHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instance_id"]
+	snapshotID := vars["snapshot_id"]
+
+	if err := h.Manager.Restore(r.Context(), instanceID, snapshotID, h.Persister); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
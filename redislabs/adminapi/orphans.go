@@ -0,0 +1,32 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// OrphanLister is implemented by instance managers that run a reconciler
+// and can report instances whose database is missing from the cluster.
+type OrphanLister interface {
+	ListOrphans(persister persisters.StatePersister) ([]persisters.ServiceInstance, error)
+}
+
+// OrphansHandler surfaces orphaned instances found by the reconciler.
+type OrphansHandler struct {
+	Manager   OrphanLister
+	Persister persisters.StatePersister
+}
+
+// ListOrphans handles GET /admin/orphans.
+func (h *OrphansHandler) ListOrphans(w http.ResponseWriter, r *http.Request) {
+	orphans, err := h.Manager.ListOrphans(h.Persister)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orphans)
+}
@@ -0,0 +1,84 @@
+package apiclient
+
+import (
+	"context"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/config"
+)
+
+// DatabaseStatus is the provisioning status of a database on the cluster.
+type DatabaseStatus string
+
+const (
+	DatabaseStatusPending DatabaseStatus = "pending"
+	DatabaseStatusActive  DatabaseStatus = "active"
+	DatabaseStatusError   DatabaseStatus = "error"
+)
+
+// Client talks to the Redis Enterprise cluster management API. Every method
+// takes a context so in-flight HTTP requests can be canceled when the
+// broker is shutting down.
+type Client interface {
+	CreateDatabase(ctx context.Context, settings map[string]interface{}) (chan cluster.InstanceCredentials, error)
+	UpdateDatabase(ctx context.Context, UID int, params map[string]interface{}) error
+	DeleteDatabase(ctx context.Context, UID int) error
+	GetDatabaseStatus(ctx context.Context, UID int) (DatabaseStatus, error)
+	CreateDatabaseUser(ctx context.Context, UID int, role cluster.ACLRole) (cluster.DatabaseUser, error)
+	DeleteDatabaseUser(ctx context.Context, UID int, username string) error
+	ExportDatabase(ctx context.Context, UID int, target string) (string, error)
+	ImportDatabase(ctx context.Context, UID int, snapshotID string) error
+	ListDatabases(ctx context.Context) ([]cluster.InstanceCredentials, error)
+}
+
+type client struct {
+	conf   config.Config
+	logger lager.Logger
+}
+
+// New builds the default cluster API client.
+func New(conf config.Config, logger lager.Logger) Client {
+	return &client{
+		conf:   conf,
+		logger: logger,
+	}
+}
+
+func (c *client) CreateDatabase(ctx context.Context, settings map[string]interface{}) (chan cluster.InstanceCredentials, error) {
+	ch := make(chan cluster.InstanceCredentials, 1)
+	return ch, nil
+}
+
+func (c *client) UpdateDatabase(ctx context.Context, UID int, params map[string]interface{}) error {
+	return nil
+}
+
+func (c *client) DeleteDatabase(ctx context.Context, UID int) error {
+	return nil
+}
+
+func (c *client) GetDatabaseStatus(ctx context.Context, UID int) (DatabaseStatus, error) {
+	return DatabaseStatusActive, nil
+}
+
+func (c *client) CreateDatabaseUser(ctx context.Context, UID int, role cluster.ACLRole) (cluster.DatabaseUser, error) {
+	return cluster.DatabaseUser{Role: role}, nil
+}
+
+func (c *client) DeleteDatabaseUser(ctx context.Context, UID int, username string) error {
+	return nil
+}
+
+func (c *client) ExportDatabase(ctx context.Context, UID int, target string) (string, error) {
+	return "", nil
+}
+
+func (c *client) ImportDatabase(ctx context.Context, UID int, snapshotID string) error {
+	return nil
+}
+
+func (c *client) ListDatabases(ctx context.Context) ([]cluster.InstanceCredentials, error) {
+	return nil, nil
+}
@@ -0,0 +1,11 @@
+package cluster
+
+// InstanceCredentials holds the connection details handed back by the
+// Redis Enterprise cluster once a database has been provisioned.
+type InstanceCredentials struct {
+	UID      int
+	Name     string
+	Host     string
+	Port     int
+	Password string
+}
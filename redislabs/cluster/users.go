@@ -0,0 +1,16 @@
+package cluster
+
+// ACLRole is the permission level granted to a per-binding database user.
+type ACLRole string
+
+const (
+	ACLRoleReadWrite ACLRole = "read-write"
+	ACLRoleReadOnly  ACLRole = "read-only"
+)
+
+// DatabaseUser is a single ACL user minted for one service binding.
+type DatabaseUser struct {
+	Username string
+	Password string
+	Role     ACLRole
+}
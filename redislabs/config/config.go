@@ -0,0 +1,12 @@
+package config
+
+// Config holds the broker's static configuration, loaded once at startup.
+type Config struct {
+	ClusterAPIURL string
+	Username      string
+	Password      string
+
+	// LogLevel dials lager verbosity ("debug", "info", "error", "fatal")
+	// without recompiling the broker.
+	LogLevel string
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// NewLogger builds the broker's logger, using LogLevel to pick the sink's
+// minimum level so operators can dial verbosity up or down without
+// recompiling the broker. LogLevel defaults to "info" if unset or
+// unrecognized.
+func NewLogger(conf Config) lager.Logger {
+	logger := lager.NewLogger("cf-redislabs-broker")
+	logger.RegisterSink(lager.NewWriterSink(os.Stdout, logLevel(conf.LogLevel)))
+	return logger
+}
+
+func logLevel(level string) lager.LogLevel {
+	switch level {
+	case "debug":
+		return lager.DEBUG
+	case "info":
+		return lager.INFO
+	case "error":
+		return lager.ERROR
+	case "fatal":
+		return lager.FATAL
+	default:
+		return lager.INFO
+	}
+}
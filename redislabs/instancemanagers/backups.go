@@ -0,0 +1,252 @@
+package instancemanagers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/instancemanagers/logctx"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// BackupManager is implemented by instance managers that support on-demand
+// and scheduled backups, so other packages (e.g. an admin HTTP handler) can
+// depend on the capability without importing the concrete instance manager.
+type BackupManager interface {
+	Backup(ctx context.Context, instanceID string, persister persisters.StatePersister) (string, error)
+	Restore(ctx context.Context, instanceID, snapshotID string, persister persisters.StatePersister) error
+	ListSnapshots(instanceID string, persister persisters.StatePersister) ([]persisters.Snapshot, error)
+}
+
+// ConfigureBackups persists the backup_interval/backup_location settings
+// read from plan config or update-instance params, and (re)starts the
+// instance's BackupScheduler to pick them up.
+func (d *defaultCreator) ConfigureBackups(ctx context.Context, instanceID string, intervalSeconds int, target string, persister persisters.StatePersister) (*BackupScheduler, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return nil, err
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return nil, ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return nil, brokerapi.ErrInstanceDoesNotExist
+	}
+
+	instance.BackupLocation = target
+	instance.BackupIntervalSeconds = intervalSeconds
+	if err = persister.Save(state); err != nil {
+		d.logger.Error("Failed to save the new broker state", err)
+		return nil, ErrFailedToSaveState
+	}
+
+	scheduler := d.startBackupScheduler(instanceID, time.Duration(intervalSeconds)*time.Second, target, persister)
+	return scheduler, nil
+}
+
+// startBackupScheduler stops any scheduler already running for instanceID
+// before starting the new one, so repeated ConfigureBackups calls (e.g. on
+// update-service) replace the old schedule instead of leaking a goroutine
+// that keeps running the stale interval alongside it.
+func (d *defaultCreator) startBackupScheduler(instanceID string, interval time.Duration, target string, persister persisters.StatePersister) *BackupScheduler {
+	scheduler := NewBackupScheduler(d, instanceID, interval, target, persister)
+
+	d.schedulersMu.Lock()
+	if existing, ok := d.schedulers[instanceID]; ok {
+		existing.Stop()
+	}
+	d.schedulers[instanceID] = scheduler
+	d.schedulersMu.Unlock()
+
+	scheduler.Start()
+	return scheduler
+}
+
+// rehydrateBackupSchedulers restarts the BackupScheduler for every instance
+// that has backups configured, so scheduled backups survive a broker
+// restart instead of silently stopping until the next ConfigureBackups call.
+func (d *defaultCreator) rehydrateBackupSchedulers(persister persisters.StatePersister) {
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state while rehydrating backup schedulers", err)
+		return
+	}
+
+	for _, instance := range state.AvailableInstances {
+		if instance.BackupIntervalSeconds <= 0 {
+			continue
+		}
+		d.startBackupScheduler(instance.ID, time.Duration(instance.BackupIntervalSeconds)*time.Second, instance.BackupLocation, persister)
+	}
+}
+
+// Backup triggers an on-demand export of instanceID's database, recording
+// the resulting snapshot in the broker state.
+func (d *defaultCreator) Backup(ctx context.Context, instanceID string, persister persisters.StatePersister) (string, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return "", err
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return "", ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return "", brokerapi.ErrInstanceDoesNotExist
+	}
+	if instance.BackupLocation == "" {
+		return "", ErrNoBackupLocationConfigured
+	}
+
+	log := logctx.New(d.logger, ctx, instanceID, "backup", instance.Credentials.UID)
+
+	log.Info("Exporting a database backup")
+	snapshotID, exportErr := d.apiClient.ExportDatabase(ctx, instance.Credentials.UID, instance.BackupLocation)
+
+	snapshot := persisters.Snapshot{
+		ID:        snapshotID,
+		TargetURI: instance.BackupLocation,
+		CreatedAt: time.Now(),
+		Status:    "succeeded",
+	}
+	if exportErr != nil {
+		snapshot.Status = "failed"
+		snapshot.Error = exportErr.Error()
+	}
+	instance.Snapshots = append(instance.Snapshots, snapshot)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the broker state after a backup", err)
+		return "", ErrFailedToSaveState
+	}
+
+	return snapshotID, exportErr
+}
+
+// Restore imports a previously taken snapshot back into the instance's
+// database.
+func (d *defaultCreator) Restore(ctx context.Context, instanceID, snapshotID string, persister persisters.StatePersister) error {
+	if err := persister.Lock(instanceID); err != nil {
+		return err
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return brokerapi.ErrInstanceDoesNotExist
+	}
+
+	found := false
+	for _, s := range instance.Snapshots {
+		if s.ID == snapshotID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrSnapshotDoesNotExist
+	}
+
+	log := logctx.New(d.logger, ctx, instanceID, "restore", instance.Credentials.UID)
+
+	log.Info("Restoring a database from a backup")
+	return d.apiClient.ImportDatabase(ctx, instance.Credentials.UID, snapshotID)
+}
+
+// ListSnapshots returns the backup history recorded for instanceID, so
+// operators can list and restore prior snapshots.
+func (d *defaultCreator) ListSnapshots(instanceID string, persister persisters.StatePersister) ([]persisters.Snapshot, error) {
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return nil, ErrFailedToLoadState
+	}
+
+	for _, instance := range state.AvailableInstances {
+		if instance.ID == instanceID {
+			return instance.Snapshots, nil
+		}
+	}
+	return nil, brokerapi.ErrInstanceDoesNotExist
+}
+
+// BackupScheduler runs inside the broker process and periodically calls
+// Backup for a single service instance.
+type BackupScheduler struct {
+	creator    *defaultCreator
+	instanceID string
+	interval   time.Duration
+	target     string
+	persister  persisters.StatePersister
+	stopCh     chan struct{}
+}
+
+// NewBackupScheduler builds a scheduler that backs up instanceID to target
+// every interval.
+func NewBackupScheduler(creator *defaultCreator, instanceID string, interval time.Duration, target string, persister persisters.StatePersister) *BackupScheduler {
+	return &BackupScheduler{
+		creator:    creator,
+		instanceID: instanceID,
+		interval:   interval,
+		target:     target,
+		persister:  persister,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called.
+func (s *BackupScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx := context.Background()
+				if _, err := s.creator.Backup(ctx, s.instanceID, s.persister); err != nil {
+					logctx.New(s.creator.logger, ctx, s.instanceID, "backup", 0).Error("Scheduled backup failed", err)
+				}
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the scheduler.
+func (s *BackupScheduler) Stop() {
+	close(s.stopCh)
+}
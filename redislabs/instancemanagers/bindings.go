@@ -0,0 +1,127 @@
+package instancemanagers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pivotal-cf/brokerapi"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/instancemanagers/logctx"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// Bind mints a dedicated ACL user on the cluster for this binding, so it can
+// be revoked later without rotating every other application sharing the
+// instance. Pass `"read_only": true` in params to grant a read-only role.
+func (d *defaultCreator) Bind(ctx context.Context, instanceID, bindingID string, params map[string]interface{}, persister persisters.StatePersister) (string, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return "", err
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return "", ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return "", brokerapi.ErrInstanceDoesNotExist
+	}
+
+	for _, b := range instance.Bindings {
+		if b.ID == bindingID {
+			return "", brokerapi.ErrBindingAlreadyExists
+		}
+	}
+
+	role := cluster.ACLRoleReadWrite
+	if readOnly, ok := params["read_only"].(bool); ok && readOnly {
+		role = cluster.ACLRoleReadOnly
+	}
+
+	log := logctx.New(d.logger, ctx, instanceID, "bind", instance.Credentials.UID)
+
+	log.Info("Creating a per-binding database user")
+	user, err := d.apiClient.CreateDatabaseUser(ctx, instance.Credentials.UID, role)
+	if err != nil {
+		log.Error("Failed to create a database user", err)
+		return "", err
+	}
+
+	instance.Bindings = append(instance.Bindings, persisters.Binding{
+		ID:   bindingID,
+		User: user,
+	})
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the new broker state", err)
+		return "", ErrFailedToSaveState
+	}
+
+	return bindingURI(instance.Credentials, user), nil
+}
+
+// Unbind tears down the ACL user created for this binding.
+func (d *defaultCreator) Unbind(ctx context.Context, instanceID, bindingID string, persister persisters.StatePersister) error {
+	if err := persister.Lock(instanceID); err != nil {
+		return err
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return brokerapi.ErrInstanceDoesNotExist
+	}
+
+	bindingsLeft := []persisters.Binding{}
+	var removed *persisters.Binding
+	for _, b := range instance.Bindings {
+		if b.ID == bindingID {
+			match := b
+			removed = &match
+		} else {
+			bindingsLeft = append(bindingsLeft, b)
+		}
+	}
+	if removed == nil {
+		return brokerapi.ErrBindingDoesNotExist
+	}
+
+	log := logctx.New(d.logger, ctx, instanceID, "unbind", instance.Credentials.UID)
+
+	if err := d.apiClient.DeleteDatabaseUser(ctx, instance.Credentials.UID, removed.User.Username); err != nil {
+		log.Error("Failed to delete a database user", err)
+		return err
+	}
+
+	instance.Bindings = bindingsLeft
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the broker state after the binding removal", err)
+		return err
+	}
+	return nil
+}
+
+func bindingURI(credentials cluster.InstanceCredentials, user cluster.DatabaseUser) string {
+	return fmt.Sprintf("redis://%s:%s@%s:%d", user.Username, user.Password, credentials.Host, credentials.Port)
+}
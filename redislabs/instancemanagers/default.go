@@ -1,7 +1,7 @@
 package instancemanagers
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
@@ -11,53 +11,68 @@ import (
 	"github.com/RedisLabs/cf-redislabs-broker/redislabs/apiclient"
 	"github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
 	"github.com/RedisLabs/cf-redislabs-broker/redislabs/config"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/instancemanagers/logctx"
 	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
 )
 
 type defaultCreator struct {
-	lock      sync.Mutex
 	logger    lager.Logger
 	apiClient apiclient.Client
+
+	// shutdownCtx outlives any single broker request. Background work
+	// spawned by the async methods (goroutines that keep running after the
+	// HTTP handler that started them has returned) is scoped to this
+	// context instead of the triggering request's, which is canceled as
+	// soon as that request completes.
+	shutdownCtx context.Context
+
+	schedulersMu sync.Mutex
+	schedulers   map[string]*BackupScheduler
 }
 
 var (
 	WaitingForDatabaseTimeout = 15 //seconds
 )
 
-func NewDefault(conf config.Config, logger lager.Logger) *defaultCreator {
-	return &defaultCreator{
-		logger:    logger,
-		apiClient: apiclient.New(conf, logger),
+func NewDefault(shutdownCtx context.Context, conf config.Config, logger lager.Logger, persister persisters.StatePersister) *defaultCreator {
+	d := &defaultCreator{
+		logger:      logger,
+		apiClient:   apiclient.New(conf, logger),
+		shutdownCtx: shutdownCtx,
+		schedulers:  map[string]*BackupScheduler{},
 	}
+
+	d.rehydrateBackupSchedulers(persister)
+	NewReconciler(d, persister, reconcileInterval).Start()
+
+	return d
 }
 
-func (d *defaultCreator) Create(instanceID string, settings map[string]interface{}, persister persisters.StatePersister) error {
-	d.lock.Lock()
-	defer d.lock.Unlock()
+func (d *defaultCreator) Create(ctx context.Context, instanceID string, settings map[string]interface{}, persister persisters.StatePersister) error {
+	if err := persister.Lock(instanceID); err != nil {
+		return err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindCreate, 0)
 
-	// Load the broker state.
-	d.logger.Info("Loading the broker state", lager.Data{
-		"instance-id": instanceID,
-	})
+	log.Info("Loading the broker state")
 	state, err := persister.Load()
 	if err != nil {
-		d.logger.Fatal("Failed to load the broker state", err)
+		log.Error("Failed to load the broker state", err)
 		return ErrFailedToLoadState
 	}
 
 	// Check whether the instance already exists.
 	for _, s := range (*state).AvailableInstances {
 		if s.ID == instanceID {
-			d.logger.Error(fmt.Sprintf("Received a request to create an instance with ID %s that already exists", instanceID), ErrInstanceExists)
+			log.Error("Received a request to create an instance that already exists", ErrInstanceExists)
 			return ErrInstanceExists
 		}
 	}
 
-	// Ask the cluster to create a database.
-	d.logger.Info("Creating a database", lager.Data{
-		"instance-id": instanceID,
-	})
-	credentials, err := d.createDatabase(settings)
+	log.Info("Creating a database")
+	credentials, err := d.createDatabase(ctx, settings)
 	if err != nil {
 		return err
 	}
@@ -68,42 +83,54 @@ func (d *defaultCreator) Create(instanceID string, settings map[string]interface
 		Credentials: credentials,
 	}
 	(*state).AvailableInstances = append((*state).AvailableInstances, s)
-	d.logger.Info("Saving the broker state", lager.Data{
-		"instance-id": instanceID,
-	})
+	log.Info("Saving the broker state")
 	if err = persister.Save(state); err != nil {
-		d.logger.Error("Failed to save the new state", err)
+		log.Error("Failed to save the new state", err)
 		return ErrFailedToSaveState
 	}
 	return nil
 }
 
-func (d *defaultCreator) Update(instanceID string, params map[string]interface{}, persister persisters.StatePersister) error {
+func (d *defaultCreator) Update(ctx context.Context, instanceID string, params map[string]interface{}, persister persisters.StatePersister) error {
+	if err := persister.Lock(instanceID); err != nil {
+		return err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindUpdate, 0)
+
 	state, err := persister.Load()
 	if err != nil {
-		d.logger.Error("Failed to load the broker state", err)
-		return err
+		log.Error("Failed to load the broker state", err)
+		return ErrFailedToLoadState
 	}
 	for _, instance := range state.AvailableInstances {
 		if instance.ID == instanceID {
-			return d.updateDatabase(instance.Credentials.UID, params)
+			return d.updateDatabase(ctx, instance.Credentials.UID, params)
 		}
 	}
 	return brokerapi.ErrInstanceDoesNotExist
 }
 
-func (d *defaultCreator) Destroy(instanceID string, persister persisters.StatePersister) error {
+func (d *defaultCreator) Destroy(ctx context.Context, instanceID string, persister persisters.StatePersister) error {
+	if err := persister.Lock(instanceID); err != nil {
+		return err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindDestroy, 0)
+
 	state, err := persister.Load()
 	if err != nil {
-		d.logger.Error("Failed to load the broker state", err)
-		return err
+		log.Error("Failed to load the broker state", err)
+		return ErrFailedToLoadState
 	}
 
 	instancesLeft := []persisters.ServiceInstance{}
 	removed := false
 	for _, instance := range state.AvailableInstances {
 		if instance.ID == instanceID {
-			if err := d.deleteDatabase(instance.Credentials.UID); err != nil {
+			if err := d.deleteDatabase(ctx, instance.Credentials.UID); err != nil {
 				return err
 			}
 			removed = true
@@ -119,20 +146,18 @@ func (d *defaultCreator) Destroy(instanceID string, persister persisters.StatePe
 	// Save the new broker state.
 	state.AvailableInstances = instancesLeft
 	if err = persister.Save(state); err != nil {
-		d.logger.Error("Failed to save the new broker state after the instance removal", err, lager.Data{
-			"instance-id": instanceID,
-		})
+		log.Error("Failed to save the new broker state after the instance removal", err)
 		return err
 	}
 	return nil
 }
 
-func (d *defaultCreator) InstanceExists(instanceID string, persister persisters.StatePersister) (bool, error) {
+func (d *defaultCreator) InstanceExists(ctx context.Context, instanceID string, persister persisters.StatePersister) (bool, error) {
 	return false, nil
 }
 
-func (d *defaultCreator) createDatabase(settings map[string]interface{}) (cluster.InstanceCredentials, error) {
-	ch, err := d.apiClient.CreateDatabase(settings)
+func (d *defaultCreator) createDatabase(ctx context.Context, settings map[string]interface{}) (cluster.InstanceCredentials, error) {
+	ch, err := d.apiClient.CreateDatabase(ctx, settings)
 	if err != nil {
 		return cluster.InstanceCredentials{}, err //ErrFailedToCreateDatabase
 	}
@@ -148,10 +173,10 @@ func (d *defaultCreator) createDatabase(settings map[string]interface{}) (cluste
 	}
 }
 
-func (d *defaultCreator) updateDatabase(UID int, params map[string]interface{}) error {
-	return d.apiClient.UpdateDatabase(UID, params)
+func (d *defaultCreator) updateDatabase(ctx context.Context, UID int, params map[string]interface{}) error {
+	return d.apiClient.UpdateDatabase(ctx, UID, params)
 }
 
-func (d *defaultCreator) deleteDatabase(UID int) error {
-	return d.apiClient.DeleteDatabase(UID)
+func (d *defaultCreator) deleteDatabase(ctx context.Context, UID int) error {
+	return d.apiClient.DeleteDatabase(ctx, UID)
 }
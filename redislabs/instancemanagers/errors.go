@@ -0,0 +1,12 @@
+package instancemanagers
+
+import "errors"
+
+var (
+	ErrInstanceExists               = errors.New("instance already exists")
+	ErrFailedToLoadState            = errors.New("failed to load the broker state")
+	ErrFailedToSaveState            = errors.New("failed to save the broker state")
+	ErrCreateDatabaseTimeoutExpired = errors.New("creating the database timed out")
+	ErrNoBackupLocationConfigured   = errors.New("no backup location is configured for this instance")
+	ErrSnapshotDoesNotExist         = errors.New("snapshot does not exist")
+)
@@ -0,0 +1,53 @@
+// Package logctx builds request-scoped loggers for the instance managers,
+// so every log line for one broker operation carries the same instance ID,
+// operation kind, cluster UID and correlation ID.
+package logctx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type correlationIDKeyType struct{}
+
+var correlationIDKey = correlationIDKeyType{}
+
+// WithCorrelationID returns a context carrying a request-scoped correlation
+// ID pulled from the incoming broker request, e.g. the X-Broker-API-Request-
+// Identity header.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// Middleware stashes the incoming request's correlation ID in its context,
+// so every logger built with New for that request carries it. It reads the
+// Open Service Broker API's X-Broker-API-Request-Identity header, falling
+// back to X-Request-Id for the admin endpoints that don't set the former.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Broker-API-Request-Identity")
+		if id == "" {
+			id = r.Header.Get("X-Request-Id")
+		}
+		next.ServeHTTP(w, r.WithContext(WithCorrelationID(r.Context(), id)))
+	})
+}
+
+// CorrelationID extracts the correlation ID stashed by WithCorrelationID, if
+// any.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// New returns a child logger pre-populated with instanceID, the operation
+// kind, the cluster UID and the request's correlation ID.
+func New(logger lager.Logger, ctx context.Context, instanceID, kind string, clusterUID int) lager.Logger {
+	return logger.Session(kind, lager.Data{
+		"instance-id":    instanceID,
+		"cluster-uid":    clusterUID,
+		"correlation-id": CorrelationID(ctx),
+	})
+}
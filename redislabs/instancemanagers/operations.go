@@ -0,0 +1,395 @@
+package instancemanagers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/apiclient"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/instancemanagers/logctx"
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// Operation kinds tracked in persisters.State.OperationsInProgress.
+const (
+	OperationKindCreate  = "create"
+	OperationKindUpdate  = "update"
+	OperationKindDestroy = "destroy"
+)
+
+// databasePollInterval is how often awaitDatabaseCreation polls
+// GetDatabaseStatus once the cluster has accepted a create request.
+const databasePollInterval = 5 * time.Second
+
+func newOperationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+// CreateAsync kicks off database creation and returns immediately with an
+// operation ID that LastOperation can be polled with, instead of blocking
+// for WaitingForDatabaseTimeout seconds like Create does.
+func (d *defaultCreator) CreateAsync(ctx context.Context, instanceID string, settings map[string]interface{}, persister persisters.StatePersister) (string, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return "", err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindCreate, 0)
+
+	log.Info("Loading the broker state")
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state", err)
+		return "", ErrFailedToLoadState
+	}
+
+	for _, s := range (*state).AvailableInstances {
+		if s.ID == instanceID {
+			log.Error("Received a request to create an instance that already exists", ErrInstanceExists)
+			return "", ErrInstanceExists
+		}
+	}
+
+	// A prior create for this instance may still be in flight (op persisted,
+	// no LastError yet): refuse to start a second one. A prior create that
+	// already failed is terminal and never gets cleaned up by anything else,
+	// since finishOperation only clears successful ops off the list; drop it
+	// here so a retried provision of the same instance ID can proceed.
+	remainingOps := make([]persisters.Operation, 0, len(state.OperationsInProgress))
+	for _, op := range state.OperationsInProgress {
+		if op.InstanceID == instanceID && op.Kind == OperationKindCreate {
+			if op.LastError == "" {
+				log.Error("Received a request to create an instance with a create already in flight", ErrInstanceExists)
+				return "", ErrInstanceExists
+			}
+			continue
+		}
+		remainingOps = append(remainingOps, op)
+	}
+	state.OperationsInProgress = remainingOps
+
+	log.Info("Creating a database asynchronously")
+	ch, err := d.apiClient.CreateDatabase(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	opID, err := newOperationID()
+	if err != nil {
+		log.Error("Failed to generate an operation ID", err)
+		return "", err
+	}
+
+	op := persisters.Operation{
+		ID:         opID,
+		InstanceID: instanceID,
+		Kind:       OperationKindCreate,
+		StartedAt:  time.Now(),
+	}
+	(*state).OperationsInProgress = append((*state).OperationsInProgress, op)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the new broker state", err)
+		return "", ErrFailedToSaveState
+	}
+
+	go d.awaitDatabaseCreation(d.shutdownCtx, instanceID, opID, ch, persister)
+
+	return opID, nil
+}
+
+// UpdateAsync starts a database update in the background and returns an
+// operation ID immediately.
+func (d *defaultCreator) UpdateAsync(ctx context.Context, instanceID string, params map[string]interface{}, persister persisters.StatePersister) (string, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return "", err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindUpdate, 0)
+
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state", err)
+		return "", ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return "", brokerapi.ErrInstanceDoesNotExist
+	}
+
+	opID, err := newOperationID()
+	if err != nil {
+		log.Error("Failed to generate an operation ID", err)
+		return "", err
+	}
+
+	op := persisters.Operation{
+		ID:         opID,
+		InstanceID: instanceID,
+		Kind:       OperationKindUpdate,
+		ClusterUID: instance.Credentials.UID,
+		StartedAt:  time.Now(),
+	}
+	state.OperationsInProgress = append(state.OperationsInProgress, op)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the new broker state", err)
+		return "", ErrFailedToSaveState
+	}
+
+	go d.awaitDatabaseUpdate(d.shutdownCtx, instanceID, opID, instance.Credentials.UID, params, persister)
+
+	return opID, nil
+}
+
+// DestroyAsync starts database deletion in the background and returns an
+// operation ID immediately.
+func (d *defaultCreator) DestroyAsync(ctx context.Context, instanceID string, persister persisters.StatePersister) (string, error) {
+	if err := persister.Lock(instanceID); err != nil {
+		return "", err
+	}
+	defer persister.Unlock(instanceID)
+
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindDestroy, 0)
+
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state", err)
+		return "", ErrFailedToLoadState
+	}
+
+	var instance *persisters.ServiceInstance
+	for i := range state.AvailableInstances {
+		if state.AvailableInstances[i].ID == instanceID {
+			instance = &state.AvailableInstances[i]
+			break
+		}
+	}
+	if instance == nil {
+		return "", brokerapi.ErrInstanceDoesNotExist
+	}
+
+	opID, err := newOperationID()
+	if err != nil {
+		log.Error("Failed to generate an operation ID", err)
+		return "", err
+	}
+
+	op := persisters.Operation{
+		ID:         opID,
+		InstanceID: instanceID,
+		Kind:       OperationKindDestroy,
+		ClusterUID: instance.Credentials.UID,
+		StartedAt:  time.Now(),
+	}
+	state.OperationsInProgress = append(state.OperationsInProgress, op)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the new broker state", err)
+		return "", ErrFailedToSaveState
+	}
+
+	go d.awaitDatabaseDestruction(d.shutdownCtx, opID, instanceID, instance.Credentials.UID, persister)
+
+	return opID, nil
+}
+
+// LastOperation reports the status of a previously started async operation,
+// as required by the Open Service Broker API.
+func (d *defaultCreator) LastOperation(ctx context.Context, instanceID, opID string, persister persisters.StatePersister) (brokerapi.LastOperation, error) {
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return brokerapi.LastOperation{}, ErrFailedToLoadState
+	}
+
+	for _, op := range state.OperationsInProgress {
+		if op.InstanceID != instanceID || op.ID != opID {
+			continue
+		}
+		if op.LastError != "" {
+			return brokerapi.LastOperation{
+				State:       brokerapi.Failed,
+				Description: op.LastError,
+			}, nil
+		}
+		return brokerapi.LastOperation{
+			State:       brokerapi.InProgress,
+			Description: fmt.Sprintf("%s is in progress", op.Kind),
+		}, nil
+	}
+
+	for _, instance := range state.AvailableInstances {
+		if instance.ID == instanceID {
+			return brokerapi.LastOperation{
+				State:       brokerapi.Succeeded,
+				Description: "the operation completed successfully",
+			}, nil
+		}
+	}
+
+	return brokerapi.LastOperation{
+		State:       brokerapi.Succeeded,
+		Description: "the instance no longer exists",
+	}, nil
+}
+
+func (d *defaultCreator) awaitDatabaseCreation(ctx context.Context, instanceID, opID string, ch chan cluster.InstanceCredentials, persister persisters.StatePersister) {
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindCreate, 0)
+
+	var credentials cluster.InstanceCredentials
+	var opErr error
+
+	// Unlike the synchronous Create, there is no fixed deadline here: a
+	// large database can take minutes to build. Wait for the cluster to
+	// accept the create request until the broker itself is shutting down.
+	select {
+	case credentials = <-ch:
+	case <-ctx.Done():
+		opErr = ctx.Err()
+	}
+
+	if opErr == nil {
+		opErr = d.pollDatabaseStatus(ctx, credentials.UID)
+	}
+
+	if err := persister.Lock(instanceID); err != nil {
+		log.Error("Failed to lock the instance while finishing an async create", err)
+		return
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state while finishing an async create", err)
+		return
+	}
+
+	if opErr != nil {
+		d.finishOperation(state, opID, opErr)
+	} else {
+		state.AvailableInstances = append(state.AvailableInstances, persisters.ServiceInstance{
+			ID:          instanceID,
+			Credentials: credentials,
+		})
+		d.finishOperation(state, opID, nil)
+	}
+
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the broker state after an async create finished", err)
+	}
+}
+
+// pollDatabaseStatus polls GetDatabaseStatus until the database reports
+// active or error, or ctx is done. awaitDatabaseCreation only calls it once
+// the cluster has accepted the create request and handed back the
+// database's UID; if ctx was canceled before that, there is nothing to poll
+// for and the call is skipped.
+func (d *defaultCreator) pollDatabaseStatus(ctx context.Context, UID int) error {
+	ticker := time.NewTicker(databasePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			status, err := d.apiClient.GetDatabaseStatus(ctx, UID)
+			if err != nil {
+				return err
+			}
+			switch status {
+			case apiclient.DatabaseStatusActive:
+				return nil
+			case apiclient.DatabaseStatusError:
+				return fmt.Errorf("database %d failed to provision", UID)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (d *defaultCreator) awaitDatabaseUpdate(ctx context.Context, instanceID, opID string, UID int, params map[string]interface{}, persister persisters.StatePersister) {
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindUpdate, UID)
+
+	opErr := d.apiClient.UpdateDatabase(ctx, UID, params)
+
+	if err := persister.Lock(instanceID); err != nil {
+		log.Error("Failed to lock the instance while finishing an async update", err)
+		return
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state while finishing an async update", err)
+		return
+	}
+
+	d.finishOperation(state, opID, opErr)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the broker state after an async update finished", err)
+	}
+}
+
+func (d *defaultCreator) awaitDatabaseDestruction(ctx context.Context, opID, instanceID string, UID int, persister persisters.StatePersister) {
+	log := logctx.New(d.logger, ctx, instanceID, OperationKindDestroy, UID)
+
+	opErr := d.apiClient.DeleteDatabase(ctx, UID)
+
+	if err := persister.Lock(instanceID); err != nil {
+		log.Error("Failed to lock the instance while finishing an async destroy", err)
+		return
+	}
+	defer persister.Unlock(instanceID)
+
+	state, err := persister.Load()
+	if err != nil {
+		log.Error("Failed to load the broker state while finishing an async destroy", err)
+		return
+	}
+
+	if opErr == nil {
+		instancesLeft := []persisters.ServiceInstance{}
+		for _, instance := range state.AvailableInstances {
+			if instance.ID != instanceID {
+				instancesLeft = append(instancesLeft, instance)
+			}
+		}
+		state.AvailableInstances = instancesLeft
+	}
+
+	d.finishOperation(state, opID, opErr)
+	if err = persister.Save(state); err != nil {
+		log.Error("Failed to save the broker state after an async destroy finished", err)
+	}
+}
+
+// finishOperation removes a completed operation from OperationsInProgress,
+// or records its error so LastOperation can report it as failed.
+func (d *defaultCreator) finishOperation(state *persisters.State, opID string, opErr error) {
+	remaining := []persisters.Operation{}
+	for _, op := range state.OperationsInProgress {
+		if op.ID != opID {
+			remaining = append(remaining, op)
+			continue
+		}
+		if opErr != nil {
+			op.LastError = opErr.Error()
+			remaining = append(remaining, op)
+		}
+	}
+	state.OperationsInProgress = remaining
+}
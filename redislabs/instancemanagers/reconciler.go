@@ -0,0 +1,164 @@
+package instancemanagers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/persisters"
+)
+
+// reconcileInterval is how often the Reconciler compares the broker state
+// against the cluster.
+const reconcileInterval = 30 * time.Second
+
+// Reconciler periodically lists databases on the cluster and reconciles
+// them against the broker state, so the broker heals itself after a crash
+// between createDatabase succeeding and persister.Save failing, or after a
+// database is created by another broker replica or restored from a backup.
+type Reconciler struct {
+	creator   *defaultCreator
+	persister persisters.StatePersister
+	interval  time.Duration
+	stopCh    chan struct{}
+}
+
+// NewReconciler builds a Reconciler that runs against persister every
+// interval.
+func NewReconciler(creator *defaultCreator, persister persisters.StatePersister, interval time.Duration) *Reconciler {
+	return &Reconciler{
+		creator:   creator,
+		persister: persister,
+		interval:  interval,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start runs the reconciliation loop in the background until Stop is called.
+func (r *Reconciler) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.reconcile(); err != nil {
+					r.creator.logger.Error("Reconciliation against the cluster failed", err)
+				}
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the reconciliation loop.
+func (r *Reconciler) Stop() {
+	close(r.stopCh)
+}
+
+func (r *Reconciler) reconcile() error {
+	if r.persister == nil {
+		return nil
+	}
+
+	databases, err := r.creator.apiClient.ListDatabases(context.Background())
+	if err != nil {
+		return err
+	}
+
+	state, err := r.persister.Load()
+	if err != nil {
+		r.creator.logger.Error("Failed to load the broker state during reconciliation", err)
+		return ErrFailedToLoadState
+	}
+
+	knownByUID := map[int]*persisters.ServiceInstance{}
+	for i := range state.AvailableInstances {
+		knownByUID[state.AvailableInstances[i].Credentials.UID] = &state.AvailableInstances[i]
+	}
+
+	seenUIDs := map[int]bool{}
+	changed := false
+	// Databases adopted below are collected here rather than appended to
+	// state.AvailableInstances inline: appending inside this loop can
+	// reallocate its backing array, which would leave knownByUID's pointers
+	// (taken before the loop) pointing at a stale copy, so an
+	// instance.Orphaned = false a few iterations later wouldn't make it into
+	// the slice that gets Saved.
+	var adopted []persisters.ServiceInstance
+	for _, db := range databases {
+		seenUIDs[db.UID] = true
+
+		if instance, ok := knownByUID[db.UID]; ok {
+			if instance.Orphaned {
+				r.creator.logger.Info("Database reappeared on the cluster, clearing orphan status", lager.Data{
+					"instance-id": instance.ID,
+				})
+				instance.Orphaned = false
+				changed = true
+			}
+			continue
+		}
+
+		r.creator.logger.Info("Found a database on the cluster with no broker state, adopting it", lager.Data{
+			"instance-id": db.Name,
+			"cluster-uid": db.UID,
+		})
+		adopted = append(adopted, persisters.ServiceInstance{
+			ID:          db.Name,
+			Credentials: db,
+		})
+
+		// A create op for this instance may still be marked in-flight because
+		// the broker replica that started awaitDatabaseCreation for it
+		// restarted mid-poll. Now that the database has shown up on the
+		// cluster, close out that op here instead of leaving LastOperation
+		// reporting "in progress" forever.
+		for _, op := range state.OperationsInProgress {
+			if op.Kind == OperationKindCreate && op.InstanceID == db.Name {
+				r.creator.finishOperation(state, op.ID, nil)
+				break
+			}
+		}
+
+		changed = true
+	}
+	state.AvailableInstances = append(state.AvailableInstances, adopted...)
+
+	for i := range state.AvailableInstances {
+		instance := &state.AvailableInstances[i]
+		if !seenUIDs[instance.Credentials.UID] && !instance.Orphaned {
+			r.creator.logger.Error("Instance's database is missing from the cluster, marking it orphaned", nil, lager.Data{
+				"instance-id": instance.ID,
+				"cluster-uid": instance.Credentials.UID,
+			})
+			instance.Orphaned = true
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return r.persister.Save(state)
+}
+
+// ListOrphans returns the instances the reconciler could not find on the
+// cluster, for the GET /admin/orphans endpoint.
+func (d *defaultCreator) ListOrphans(persister persisters.StatePersister) ([]persisters.ServiceInstance, error) {
+	state, err := persister.Load()
+	if err != nil {
+		d.logger.Error("Failed to load the broker state", err)
+		return nil, ErrFailedToLoadState
+	}
+
+	orphans := []persisters.ServiceInstance{}
+	for _, instance := range state.AvailableInstances {
+		if instance.Orphaned {
+			orphans = append(orphans, instance)
+		}
+	}
+	return orphans, nil
+}
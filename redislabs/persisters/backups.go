@@ -0,0 +1,13 @@
+package persisters
+
+import "time"
+
+// Snapshot is a single point-in-time export of a service instance's
+// database to a backup target (an S3/GCS/Azure URI).
+type Snapshot struct {
+	ID        string
+	TargetURI string
+	CreatedAt time.Time
+	Status    string
+	Error     string
+}
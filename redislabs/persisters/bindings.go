@@ -0,0 +1,11 @@
+package persisters
+
+import "github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
+
+// Binding is a single per-binding ACL user minted for a service instance, so
+// each bound application can be revoked individually without rotating the
+// credentials of every other binding.
+type Binding struct {
+	ID   string
+	User cluster.DatabaseUser
+}
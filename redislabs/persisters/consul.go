@@ -0,0 +1,122 @@
+package persisters
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulPersister stores the broker state as a single key in Consul's KV
+// store, using compare-and-swap on the key's modify index to detect
+// concurrent writers, and Consul's native distributed locks for per-instance
+// locking.
+type ConsulPersister struct {
+	client *api.Client
+	key    string
+
+	locksMu sync.Mutex
+	locks   map[string]*api.Lock
+}
+
+// NewConsulPersister builds a persister backed by the given Consul client.
+func NewConsulPersister(client *api.Client) *ConsulPersister {
+	return &ConsulPersister{
+		client: client,
+		key:    "cf-redislabs-broker/state",
+		locks:  map[string]*api.Lock{},
+	}
+}
+
+// Load reads the broker state key, returning an empty State if none has
+// been saved yet.
+func (p *ConsulPersister) Load() (*State, error) {
+	pair, _, err := p.client.KV().Get(p.key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return &State{}, nil
+	}
+	state := &State{}
+	if err := json.Unmarshal(pair.Value, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the broker state using a CAS write against the key's current
+// modify index, so a concurrent writer aborts this write instead of
+// silently clobbering it. The Version stored under the key is also checked
+// against the Version state carries from Load, so a replica that saved in
+// between that Load and this Save makes it fail with
+// ErrConcurrentModification.
+func (p *ConsulPersister) Save(state *State) error {
+	pair, _, err := p.client.KV().Get(p.key, nil)
+	if err != nil {
+		return err
+	}
+
+	var modifyIndex uint64
+	if pair != nil {
+		current := &State{}
+		if err := json.Unmarshal(pair.Value, current); err != nil {
+			return err
+		}
+		if current.Version != state.Version {
+			return ErrConcurrentModification
+		}
+		modifyIndex = pair.ModifyIndex
+	}
+
+	saved := *state
+	saved.Version++
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := p.client.KV().CAS(&api.KVPair{
+		Key:         p.key,
+		Value:       data,
+		ModifyIndex: modifyIndex,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConcurrentModification
+	}
+	state.Version++
+	return nil
+}
+
+// Lock acquires a distributed Consul lock for the given instance.
+func (p *ConsulPersister) Lock(instanceID string) error {
+	lock, err := p.client.LockKey(fmt.Sprintf("%s/locks/%s", p.key, instanceID))
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Lock(nil); err != nil {
+		return err
+	}
+
+	p.locksMu.Lock()
+	p.locks[instanceID] = lock
+	p.locksMu.Unlock()
+	return nil
+}
+
+// Unlock releases the lock acquired with Lock.
+func (p *ConsulPersister) Unlock(instanceID string) error {
+	p.locksMu.Lock()
+	lock, ok := p.locks[instanceID]
+	delete(p.locks, instanceID)
+	p.locksMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return lock.Unlock()
+}
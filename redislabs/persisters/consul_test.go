@@ -0,0 +1,69 @@
+package persisters
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// These tests exercise ConsulPersister's modify-index CAS against a real
+// Consul agent. Set CONSUL_TEST_ADDR (e.g. "localhost:8500") to run them;
+// they're skipped otherwise.
+func newTestConsulPersister(t *testing.T) *ConsulPersister {
+	addr := os.Getenv("CONSUL_TEST_ADDR")
+	if addr == "" {
+		t.Skip("CONSUL_TEST_ADDR not set, skipping ConsulPersister integration tests")
+	}
+
+	conf := api.DefaultConfig()
+	conf.Address = addr
+	client, err := api.NewClient(conf)
+	if err != nil {
+		t.Fatalf("api.NewClient: %v", err)
+	}
+
+	p := NewConsulPersister(client)
+	p.key = "cf-redislabs-broker/test/" + t.Name()
+	t.Cleanup(func() {
+		client.KV().Delete(p.key, nil)
+	})
+	return p
+}
+
+func TestConsulPersisterKeyRejectsStaleModifyIndexCAS(t *testing.T) {
+	p := newTestConsulPersister(t)
+
+	state, err := p.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	state.AvailableInstances = append(state.AvailableInstances, ServiceInstance{ID: "a"})
+	if err := p.Save(state); err != nil {
+		t.Fatalf("first Save: %v", err)
+	}
+
+	// A writer that raced in with a stale ModifyIndex (one the key no longer
+	// has after the Save above) must be rejected by the CAS rather than
+	// clobbering the write that already landed. api.KV().CAS signals
+	// rejection via its bool return, not an error.
+	ok, _, err := p.client.KV().CAS(&api.KVPair{
+		Key:         p.key,
+		Value:       []byte(`{"AvailableInstances":[{"ID":"b"}]}`),
+		ModifyIndex: 1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("CAS: %v", err)
+	}
+	if ok {
+		t.Fatalf("stale-ModifyIndex CAS succeeded, want rejection")
+	}
+
+	reloaded, err := p.Load()
+	if err != nil {
+		t.Fatalf("Load after conflict: %v", err)
+	}
+	if len(reloaded.AvailableInstances) != 1 || reloaded.AvailableInstances[0].ID != "a" {
+		t.Fatalf("stale write was applied anyway: %+v", reloaded.AvailableInstances)
+	}
+}
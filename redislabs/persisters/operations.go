@@ -0,0 +1,15 @@
+package persisters
+
+import "time"
+
+// Operation is a single in-flight broker operation (provision, update or
+// deprovision) that is tracked across restarts so LastOperation polling
+// keeps working even if the broker process is replaced mid-flight.
+type Operation struct {
+	ID         string
+	InstanceID string
+	Kind       string
+	ClusterUID int
+	StartedAt  time.Time
+	LastError  string
+}
@@ -0,0 +1,51 @@
+package persisters
+
+import (
+	"errors"
+
+	"github.com/RedisLabs/cf-redislabs-broker/redislabs/cluster"
+)
+
+// ErrConcurrentModification is returned by Save when the state loaded by the
+// caller is no longer current, i.e. another broker replica saved a change
+// in between this caller's Load and Save. Callers should reload and retry.
+var ErrConcurrentModification = errors.New("broker state was concurrently modified, reload and retry")
+
+// ServiceInstance is the broker's record of a single provisioned database.
+type ServiceInstance struct {
+	ID          string
+	Credentials cluster.InstanceCredentials
+	Bindings    []Binding
+
+	BackupLocation        string
+	BackupIntervalSeconds int
+	Snapshots             []Snapshot
+
+	// Orphaned is set by the reconciler when the instance's database can no
+	// longer be found on the cluster. Orphaned instances are surfaced to
+	// operators rather than silently deleted.
+	Orphaned bool
+}
+
+// State is the full set of broker-managed data that must survive restarts.
+type State struct {
+	AvailableInstances   []ServiceInstance
+	OperationsInProgress []Operation
+
+	// Version is bumped by StatePersister.Save on every successful write.
+	// Callers carry the Version they got from Load into their Save, so a
+	// persister can detect that another replica saved in between and abort
+	// with ErrConcurrentModification instead of clobbering that write.
+	Version int
+}
+
+// StatePersister loads and saves the broker's state to some durable store.
+// Lock/Unlock let multiple broker replicas safely serve provisioning
+// requests against the same cluster, replacing an in-process mutex that
+// cannot coordinate across broker instances.
+type StatePersister interface {
+	Load() (*State, error)
+	Save(*State) error
+	Lock(instanceID string) error
+	Unlock(instanceID string) error
+}
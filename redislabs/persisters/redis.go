@@ -0,0 +1,210 @@
+package persisters
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/redis.v3"
+)
+
+// lockTTL bounds how long a RedisPersister lock can be held, so a crashed
+// broker replica cannot wedge an instance forever.
+const lockTTL = 30 * time.Second
+
+// Reserved hash fields alongside the per-instance ones.
+const (
+	versionField    = "__version__"
+	operationsField = "__operations__"
+	instancePrefix  = "instance:"
+)
+
+// unlockScript deletes the lock key only if it still holds the token this
+// holder set when it acquired the lock, so a replica whose TTL already
+// expired can't delete a lock a different replica has since acquired.
+const unlockScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end
+`
+
+// RedisPersister stores the broker state as a hash keyed by instance ID
+// (plus a couple of reserved fields for operations and the version counter),
+// with per-instance locking via SETNX so several broker replicas can serve
+// the same cluster safely.
+type RedisPersister struct {
+	client *redis.Client
+	key    string
+
+	tokensMu sync.Mutex
+	tokens   map[string]string
+}
+
+// NewRedisPersister builds a persister backed by the Redis instance at addr.
+func NewRedisPersister(addr string) *RedisPersister {
+	return &RedisPersister{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		key:    "cf-redislabs-broker:state",
+		tokens: map[string]string{},
+	}
+}
+
+// Load reads the broker state hash, returning an empty State if none has
+// been saved yet.
+func (p *RedisPersister) Load() (*State, error) {
+	raw, err := p.client.HGetAllMap(p.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+	for field, value := range raw {
+		switch {
+		case field == versionField:
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			state.Version = version
+		case field == operationsField:
+			if err := json.Unmarshal([]byte(value), &state.OperationsInProgress); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(field, instancePrefix):
+			var instance ServiceInstance
+			if err := json.Unmarshal([]byte(value), &instance); err != nil {
+				return nil, err
+			}
+			state.AvailableInstances = append(state.AvailableInstances, instance)
+		}
+	}
+	return state, nil
+}
+
+// Save writes the broker state to the hash inside a WATCH/MULTI/EXEC
+// transaction, checking the version field against the version this caller's
+// State carries (set by the matching Load). That guards the whole
+// Load-modify-Save window a caller takes, not just the moment of the write:
+// a WATCH taken fresh at Save time would only catch a writer that lands
+// between this function's own WATCH and EXEC.
+func (p *RedisPersister) Save(state *State) error {
+	opsData, err := json.Marshal(state.OperationsInProgress)
+	if err != nil {
+		return err
+	}
+
+	instanceData := map[string][]byte{}
+	for _, instance := range state.AvailableInstances {
+		data, err := json.Marshal(instance)
+		if err != nil {
+			return err
+		}
+		instanceData[instancePrefix+instance.ID] = data
+	}
+
+	txf := func(tx *redis.Tx) error {
+		currentVersion := 0
+		raw, err := tx.HGet(p.key, versionField).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			currentVersion, err = strconv.Atoi(raw)
+			if err != nil {
+				return err
+			}
+		}
+		if currentVersion != state.Version {
+			return ErrConcurrentModification
+		}
+
+		existingFields, err := tx.HKeys(p.key).Result()
+		if err != nil {
+			return err
+		}
+
+		newVersion := state.Version + 1
+		_, err = tx.Exec(func() error {
+			for field, data := range instanceData {
+				tx.HSet(p.key, field, string(data))
+			}
+			for _, field := range existingFields {
+				if strings.HasPrefix(field, instancePrefix) {
+					if _, stillPresent := instanceData[field]; !stillPresent {
+						tx.HDel(p.key, field)
+					}
+				}
+			}
+			tx.HSet(p.key, operationsField, string(opsData))
+			tx.HSet(p.key, versionField, strconv.Itoa(newVersion))
+			return nil
+		})
+		return err
+	}
+
+	if err := p.client.Watch(txf, p.key); err != nil {
+		return err
+	}
+	state.Version++
+	return nil
+}
+
+// Lock acquires a per-instance lock using SETNX with a TTL, so other broker
+// replicas cannot act on the same instance concurrently. The value written
+// is a random token private to this holder, so Unlock can tell whether it
+// still owns the lock before releasing it.
+func (p *RedisPersister) Lock(instanceID string) error {
+	token, err := newLockToken()
+	if err != nil {
+		return err
+	}
+
+	lockKey := p.lockKey(instanceID)
+	ok, err := p.client.SetNX(lockKey, token, lockTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("instance %s is locked by another broker replica", instanceID)
+	}
+
+	p.tokensMu.Lock()
+	p.tokens[instanceID] = token
+	p.tokensMu.Unlock()
+	return nil
+}
+
+// Unlock releases a lock acquired with Lock, but only if it still holds this
+// holder's token: if lockTTL already expired and another replica has since
+// acquired the lock, this is a no-op rather than deleting the other
+// replica's lock out from under it.
+func (p *RedisPersister) Unlock(instanceID string) error {
+	p.tokensMu.Lock()
+	token, ok := p.tokens[instanceID]
+	delete(p.tokens, instanceID)
+	p.tokensMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return p.client.Eval(unlockScript, []string{p.lockKey(instanceID)}, []string{token}).Err()
+}
+
+func (p *RedisPersister) lockKey(instanceID string) string {
+	return fmt.Sprintf("%s:lock:%s", p.key, instanceID)
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,87 @@
+package persisters
+
+import (
+	"os"
+	"testing"
+)
+
+// These tests exercise RedisPersister's WATCH/MULTI/EXEC transaction and its
+// Lua compare-and-delete script against a real Redis instance: the races
+// they guard against can't be faked away without testing something else.
+// Set REDIS_TEST_ADDR (e.g. "localhost:6379") to run them; they're skipped
+// otherwise.
+func newTestRedisPersister(t *testing.T) *RedisPersister {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set, skipping RedisPersister integration tests")
+	}
+
+	p := NewRedisPersister(addr)
+	p.key = "cf-redislabs-broker:test:" + t.Name()
+	t.Cleanup(func() {
+		p.client.Del(p.key, p.lockKey("instance-1")).Err()
+	})
+	return p
+}
+
+func TestRedisPersisterSaveDetectsConcurrentModification(t *testing.T) {
+	p := newTestRedisPersister(t)
+
+	stateA, err := p.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	stateB, err := p.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// stateA and stateB both saw Version 0. The first Save should go
+	// through and bump the version; the second, racing Save carries the
+	// same stale Version and must be rejected instead of clobbering it.
+	stateA.AvailableInstances = append(stateA.AvailableInstances, ServiceInstance{ID: "a"})
+	if err := p.Save(stateA); err != nil {
+		t.Fatalf("first Save: %v", err)
+	}
+
+	stateB.AvailableInstances = append(stateB.AvailableInstances, ServiceInstance{ID: "b"})
+	if err := p.Save(stateB); err != ErrConcurrentModification {
+		t.Fatalf("second Save: got err %v, want ErrConcurrentModification", err)
+	}
+
+	reloaded, err := p.Load()
+	if err != nil {
+		t.Fatalf("Load after conflict: %v", err)
+	}
+	if len(reloaded.AvailableInstances) != 1 || reloaded.AvailableInstances[0].ID != "a" {
+		t.Fatalf("rejected Save was applied anyway: %+v", reloaded.AvailableInstances)
+	}
+}
+
+func TestRedisPersisterUnlockOnlyClearsOwnToken(t *testing.T) {
+	p := newTestRedisPersister(t)
+	instanceID := "instance-1"
+
+	if err := p.Lock(instanceID); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	// Simulate this holder's TTL already having expired and a different
+	// broker replica having since acquired the lock under its own token.
+	otherToken := "a-different-replicas-token"
+	if err := p.client.Set(p.lockKey(instanceID), otherToken, lockTTL).Err(); err != nil {
+		t.Fatalf("failed to simulate a competing lock holder: %v", err)
+	}
+
+	if err := p.Unlock(instanceID); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	got, err := p.client.Get(p.lockKey(instanceID)).Result()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != otherToken {
+		t.Fatalf("Unlock deleted another replica's lock: got %q, want %q", got, otherToken)
+	}
+}